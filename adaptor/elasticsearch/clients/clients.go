@@ -0,0 +1,124 @@
+// Package clients provides the shared configuration and registration point
+// for the version specific Elasticsearch writers (v1, v2, v5, v6, v7, ...).
+package clients
+
+import (
+	"net/http"
+	"time"
+
+	"transporter/client"
+)
+
+// Creator instantiates a version specific client.Writer from the given
+// ClientOptions.
+type Creator func(*ClientOptions) (client.Writer, error)
+
+// ClientOptions are the common options shared by every versioned client.
+type ClientOptions struct {
+	URLs       []string
+	HTTPClient *http.Client
+	Index      string
+
+	// ParentID names the key, present on child documents, whose value
+	// identifies the parent document it belongs to.
+	ParentID string
+
+	// JoinField names the join field declared on the index mapping, e.g.
+	// "my_join". Leave empty to disable join-field handling.
+	JoinField string
+
+	// Relations maps each parent relation name to the list of its child
+	// relation names, mirroring the `relations` block of the join field
+	// mapping (e.g. {"company": {"employee"}}).
+	Relations map[string][]string
+
+	// Bulk enables batched writes through the _bulk API. Leave nil to issue
+	// one request per write.
+	Bulk *BulkOptions
+
+	// RequestTimeout bounds how long a single, non-bulk request may run.
+	// Zero means the request is bounded only by the context passed to
+	// WriteContext, if any.
+	RequestTimeout time.Duration
+
+	// FlushTimeout bounds how long a single bulk flush request may run,
+	// including the final flush performed by Close.
+	FlushTimeout time.Duration
+
+	// Username and Password enable HTTP basic auth on every request.
+	Username string
+	Password string
+
+	// APIKey enables Elasticsearch API key auth, sent as
+	// "Authorization: ApiKey <APIKey>". It must already be the
+	// base64-encoded "id:api_key" pair Elasticsearch expects.
+	APIKey string
+
+	// BearerToken enables bearer token auth, sent as
+	// "Authorization: Bearer <BearerToken>".
+	BearerToken string
+
+	// TLS configures the transport's TLS settings when HTTPClient is left
+	// unset. It has no effect if HTTPClient is provided.
+	TLS *TLSConfig
+
+	// Pipeline names the default ingest pipeline to run documents through.
+	// A message may override it with a "pipeline" key in its document.
+	Pipeline string
+}
+
+// TLSConfig configures the TLS settings of the *http.Transport a client
+// builds for itself when ClientOptions.HTTPClient is left unset.
+type TLSConfig struct {
+	// CAFile, if set, is a PEM bundle used instead of the system trust
+	// store to verify the server's certificate.
+	CAFile string
+
+	// CertFile and KeyFile, if set, are presented as a client certificate.
+	CertFile string
+	KeyFile  string
+
+	// InsecureSkipVerify disables server certificate verification. It
+	// should only be used against test clusters.
+	InsecureSkipVerify bool
+}
+
+// BulkOptions configures how a client batches operations before flushing
+// them through the Elasticsearch _bulk API.
+type BulkOptions struct {
+	// MaxBytes flushes the batch once the accumulated NDJSON payload
+	// reaches this many bytes.
+	MaxBytes int
+
+	// MaxActions flushes the batch once it holds this many operations.
+	MaxActions int
+
+	// FlushInterval flushes the batch on a timer, regardless of its size,
+	// so that writes aren't held indefinitely by a slow trickle of ops.
+	FlushInterval time.Duration
+
+	// MaxRetries bounds how many times a single item is retried after a
+	// retryable (429 or 5xx) response before it is treated as a failure.
+	MaxRetries int
+
+	// InitialBackoff is the base delay before the first retry of a failed
+	// item; subsequent retries back off exponentially with jitter.
+	InitialBackoff time.Duration
+}
+
+// VersionedClient holds the Creator registered for one Elasticsearch major
+// version; the version string itself is its key in Clients.
+type VersionedClient struct {
+	Creator Creator
+}
+
+// Clients holds every registered VersionedClient, keyed by Elasticsearch
+// major version (e.g. "v7"). Version packages register themselves via Add
+// from an init() function.
+var Clients = map[string]VersionedClient{}
+
+// Add registers a Creator under the given version string. It is intended to
+// be called from the init() function of each version package.
+func Add(version string, c Creator) {
+	Clients[version] = VersionedClient{Creator: c}
+}
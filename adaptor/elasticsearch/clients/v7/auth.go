@@ -0,0 +1,68 @@
+package v7
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"transporter/adaptor/elasticsearch/clients"
+)
+
+// buildHTTPClient returns opts.HTTPClient if the caller already supplied
+// one, otherwise builds one whose transport is configured from opts.TLS.
+func buildHTTPClient(opts *clients.ClientOptions) (*http.Client, error) {
+	if opts.HTTPClient != nil {
+		return opts.HTTPClient, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.TLS != nil {
+		tlsConfig, err := buildTLSConfig(opts.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+func buildTLSConfig(cfg *clients.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// setAuth adds the configured authentication header to req, if any. API key
+// auth takes precedence over a bearer token, which takes precedence over
+// basic auth.
+func setAuth(opts *clients.ClientOptions, req *http.Request) {
+	switch {
+	case opts.APIKey != "":
+		req.Header.Set("Authorization", "ApiKey "+opts.APIKey)
+	case opts.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+opts.BearerToken)
+	case opts.Username != "":
+		req.SetBasicAuth(opts.Username, opts.Password)
+	}
+}
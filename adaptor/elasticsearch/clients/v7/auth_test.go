@@ -0,0 +1,88 @@
+package v7
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"transporter/adaptor/elasticsearch/clients"
+	"transporter/message"
+	"transporter/message/ops"
+)
+
+const (
+	testAPIKey      = "ZGVtbzpjaGFuZ2VtZQ=="
+	testBearerToken = "test-bearer-token"
+)
+
+func newAuthTestServer(t *testing.T, check func(*http.Request)) *httptest.Server {
+	t.Helper()
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		check(r)
+		w.WriteHeader(http.StatusCreated)
+	}))
+}
+
+func writeOneDoc(t *testing.T, opts *clients.ClientOptions) {
+	t.Helper()
+	vc := clients.Clients["v7"]
+	w, err := vc.Creator(opts)
+	if err != nil {
+		t.Fatalf("unable to create writer, %s", err)
+	}
+	if _, err := w.Write(
+		message.From(ops.Insert, testType, map[string]interface{}{"_id": "auth", "hello": "world"}),
+	)(nil); err != nil {
+		t.Fatalf("write failed, %s", err)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	srv := newAuthTestServer(t, func(r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "elastic" || pass != "changeme" {
+			t.Errorf("unexpected basic auth, got %s:%s (ok=%v)", user, pass, ok)
+		}
+	})
+	defer srv.Close()
+
+	writeOneDoc(t, &clients.ClientOptions{
+		URLs:     []string{srv.URL},
+		Index:    defaultIndex,
+		Username: "elastic",
+		Password: "changeme",
+		TLS:      &clients.TLSConfig{InsecureSkipVerify: true},
+	})
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	srv := newAuthTestServer(t, func(r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "ApiKey "+testAPIKey {
+			t.Errorf("unexpected Authorization header, got %q", got)
+		}
+	})
+	defer srv.Close()
+
+	writeOneDoc(t, &clients.ClientOptions{
+		URLs:   []string{srv.URL},
+		Index:  defaultIndex,
+		APIKey: testAPIKey,
+		TLS:    &clients.TLSConfig{InsecureSkipVerify: true},
+	})
+}
+
+func TestBearerAuth(t *testing.T) {
+	srv := newAuthTestServer(t, func(r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer "+testBearerToken {
+			t.Errorf("unexpected Authorization header, got %q", got)
+		}
+	})
+	defer srv.Close()
+
+	writeOneDoc(t, &clients.ClientOptions{
+		URLs:        []string{srv.URL},
+		Index:       defaultIndex,
+		BearerToken: testBearerToken,
+		TLS:         &clients.TLSConfig{InsecureSkipVerify: true},
+	})
+}
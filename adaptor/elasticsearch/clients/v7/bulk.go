@@ -0,0 +1,284 @@
+package v7
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"transporter/adaptor/elasticsearch/clients"
+	"transporter/message"
+	"transporter/message/ops"
+)
+
+// pendingItem is a single operation queued for the next bulk flush.
+type pendingItem struct {
+	msg     message.Msg
+	id      string
+	meta    []byte
+	source  []byte
+	retries int
+}
+
+// bulkQueue accumulates operations for a Writer and flushes them through the
+// Elasticsearch _bulk API once a configured size, count, or time threshold
+// is reached.
+type bulkQueue struct {
+	w    *Writer
+	opts *clients.BulkOptions
+
+	mu      sync.Mutex
+	pending []*pendingItem
+	size    int
+
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func newBulkQueue(w *Writer) *bulkQueue {
+	q := &bulkQueue{w: w, opts: w.opts.Bulk, stop: make(chan struct{})}
+	if q.opts.FlushInterval > 0 {
+		q.wg.Add(1)
+		go q.flushLoop()
+	}
+	return q
+}
+
+func (q *bulkQueue) flushLoop() {
+	defer q.wg.Done()
+	t := time.NewTicker(q.opts.FlushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			q.flush()
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// enqueue translates msg into a bulk action and queues it, flushing
+// immediately if doing so crosses the MaxBytes or MaxActions threshold.
+func (q *bulkQueue) enqueue(msg message.Msg, op ops.Op, meta opMeta, d map[string]interface{}) {
+	action, source, err := buildBulkAction(op, q.w.opts.Index, meta, d)
+	if err != nil {
+		message.Error(msg, err)
+		return
+	}
+	item := &pendingItem{msg: msg, id: meta.id, meta: action, source: source}
+
+	q.mu.Lock()
+	q.pending = append(q.pending, item)
+	q.size += len(action) + len(source)
+	full := (q.opts.MaxBytes > 0 && q.size >= q.opts.MaxBytes) ||
+		(q.opts.MaxActions > 0 && len(q.pending) >= q.opts.MaxActions)
+	q.mu.Unlock()
+
+	if full {
+		q.flush()
+	}
+}
+
+// flush sends every pending item, retrying only those the cluster reports as
+// retryable until they succeed, exhaust MaxRetries, or fail outright. Each
+// send is bounded by ClientOptions.FlushTimeout so a stalled cluster can't
+// hold the flush, or a caller of close, open forever.
+func (q *bulkQueue) flush() {
+	q.mu.Lock()
+	items := q.pending
+	q.pending = nil
+	q.size = 0
+	q.mu.Unlock()
+
+	for len(items) > 0 {
+		ctx, cancel := q.flushContext()
+		items = q.send(ctx, items)
+		cancel()
+	}
+}
+
+func (q *bulkQueue) flushContext() (context.Context, context.CancelFunc) {
+	if q.w.opts.FlushTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), q.w.opts.FlushTimeout)
+}
+
+func (q *bulkQueue) send(ctx context.Context, items []*pendingItem) []*pendingItem {
+	var buf bytes.Buffer
+	for _, it := range items {
+		buf.Write(it.meta)
+		buf.WriteByte('\n')
+		if it.source != nil {
+			buf.Write(it.source)
+			buf.WriteByte('\n')
+		}
+	}
+
+	url := fmt.Sprintf("%s/%s/_bulk", q.w.opts.URLs[0], q.w.opts.Index)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		q.failAll(items, err)
+		return nil
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	setAuth(q.w.opts, req)
+
+	resp, err := q.w.opts.HTTPClient.Do(req)
+	if err != nil {
+		q.failAll(items, err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	var br bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&br); err != nil {
+		q.failAll(items, err)
+		return nil
+	}
+	if len(br.Items) != len(items) {
+		q.failAll(items, fmt.Errorf("bulk response had %d items, expected %d", len(br.Items), len(items)))
+		return nil
+	}
+
+	var retry []*pendingItem
+	for i, it := range items {
+		result := firstResult(br.Items[i])
+		switch {
+		case result.Status < 300:
+			message.Confirms(it.msg)
+		case result.Status == http.StatusTooManyRequests || result.Status >= 500:
+			it.retries++
+			if it.retries > q.opts.MaxRetries {
+				message.Error(it.msg, fmt.Errorf("elasticsearch bulk item failed with status %d after %d retries", result.Status, it.retries-1))
+				continue
+			}
+			retry = append(retry, it)
+		case result.Status == http.StatusConflict:
+			message.Error(it.msg, &VersionConflictError{ID: it.id})
+		default:
+			message.Error(it.msg, fmt.Errorf("elasticsearch bulk item failed with status %d", result.Status))
+		}
+	}
+
+	if len(retry) > 0 {
+		q.backoff(retry[0].retries)
+	}
+	return retry
+}
+
+// backoff sleeps for an exponentially increasing, jittered delay before the
+// next retry attempt.
+func (q *bulkQueue) backoff(attempt int) {
+	base := q.opts.InitialBackoff
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	delay := base * time.Duration(1<<uint(attempt-1))
+	delay += time.Duration(rand.Int63n(int64(base)))
+	time.Sleep(delay)
+}
+
+func (q *bulkQueue) failAll(items []*pendingItem, err error) {
+	for _, it := range items {
+		message.Error(it.msg, err)
+	}
+}
+
+// close stops the background flush loop and synchronously drains any
+// pending operations. The final flush is bounded by the same
+// ClientOptions.FlushTimeout as any other flush, so a stalled cluster
+// causes the pending items to fail with an error, reported through
+// message.Error, rather than Close blocking indefinitely; close itself
+// always returns nil. It is safe to call close more than once; only the
+// first call stops the loop and flushes.
+func (q *bulkQueue) close() error {
+	q.closeOnce.Do(func() {
+		close(q.stop)
+		q.wg.Wait()
+		q.flush()
+	})
+	return nil
+}
+
+// bulkResponse is the subset of the Elasticsearch _bulk response body this
+// writer needs in order to confirm or retry individual items.
+type bulkResponse struct {
+	Items []map[string]bulkItemResult `json:"items"`
+}
+
+type bulkItemResult struct {
+	ID     string          `json:"_id"`
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// firstResult returns the single result carried by a bulk response item; it
+// is keyed by the action name ("index", "update", or "delete"), which the
+// caller already knows and doesn't need repeated.
+func firstResult(m map[string]bulkItemResult) bulkItemResult {
+	for _, v := range m {
+		return v
+	}
+	return bulkItemResult{}
+}
+
+// buildBulkAction renders msg's operation as a bulk metadata line and, for
+// index/update operations, the accompanying source line.
+func buildBulkAction(op ops.Op, index string, meta opMeta, d map[string]interface{}) (action, source []byte, err error) {
+	a := map[string]interface{}{}
+	switch op {
+	case ops.Delete:
+		a["delete"] = bulkMeta(index, meta)
+	case ops.Update:
+		a["update"] = bulkMeta(index, meta)
+		source, err = json.Marshal(map[string]interface{}{"doc": d})
+	default:
+		name := "index"
+		if meta.opType == "create" {
+			name = "create"
+		}
+		a[name] = bulkMeta(index, meta)
+		source, err = json.Marshal(d)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	action, err = json.Marshal(a)
+	return action, source, err
+}
+
+// bulkMeta renders the metadata object of a single bulk action line.
+// pipeline only takes effect on index/create actions, which Elasticsearch
+// enforces; it is harmless to include it unconditionally here.
+func bulkMeta(index string, meta opMeta) map[string]interface{} {
+	m := map[string]interface{}{"_index": index}
+	if meta.id != "" {
+		m["_id"] = meta.id
+	}
+	if meta.routing != "" {
+		m["routing"] = meta.routing
+	}
+	if meta.pipeline != "" {
+		m["pipeline"] = meta.pipeline
+	}
+	if meta.version != "" {
+		m["version"] = meta.version
+	}
+	if meta.versionType != "" {
+		m["version_type"] = meta.versionType
+	}
+	if meta.ifSeqNo != "" {
+		m["if_seq_no"] = meta.ifSeqNo
+	}
+	if meta.ifPrimaryTerm != "" {
+		m["if_primary_term"] = meta.ifPrimaryTerm
+	}
+	return m
+}
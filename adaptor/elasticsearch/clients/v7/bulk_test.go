@@ -0,0 +1,253 @@
+package v7
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"transporter/adaptor/elasticsearch/clients"
+	"transporter/client"
+	"transporter/message"
+	"transporter/message/ops"
+)
+
+// stubBulkTransport answers _bulk requests with a canned per-item response,
+// returning a 429 for "_id":"2" on the first call and a success on the
+// second, so the writer's retry path gets exercised.
+type stubBulkTransport struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (s *stubBulkTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.mu.Lock()
+	s.calls++
+	call := s.calls
+	s.mu.Unlock()
+
+	var items string
+	switch call {
+	case 1:
+		items = `[{"index":{"_id":"1","status":201}},` +
+			`{"index":{"_id":"2","status":429}},` +
+			`{"index":{"_id":"3","status":400,"error":{"type":"mapper_parsing_exception"}}}]`
+	case 2:
+		items = `[{"index":{"_id":"2","status":201}}]`
+	default:
+		items = `[]`
+	}
+	body := fmt.Sprintf(`{"items":%s}`, items)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Header:     make(http.Header),
+	}, nil
+}
+
+// TestBulkWriter flushes a full batch, retries the item reported as 429
+// until it succeeds, and leaves the 400 item unconfirmed.
+func TestBulkWriter(t *testing.T) {
+	confirms := make(chan struct{}, 10)
+	opts := &clients.ClientOptions{
+		URLs:       []string{testURL},
+		HTTPClient: &http.Client{Transport: &stubBulkTransport{}},
+		Index:      defaultIndex,
+		Bulk: &clients.BulkOptions{
+			MaxActions:     3,
+			MaxRetries:     3,
+			InitialBackoff: time.Millisecond,
+		},
+	}
+	vc := clients.Clients["v7"]
+	w, _ := vc.Creator(opts)
+
+	for _, id := range []string{"1", "2", "3"} {
+		w.Write(
+			message.WithConfirms(
+				confirms,
+				message.From(ops.Insert, testType, map[string]interface{}{"_id": id})),
+		)(nil)
+	}
+	w.(client.Closer).Close()
+
+	got := 0
+drain:
+	for {
+		select {
+		case <-confirms:
+			got++
+		case <-time.After(100 * time.Millisecond):
+			break drain
+		}
+	}
+	if got != 2 {
+		t.Errorf("expected 2 confirmed items, got %d", got)
+	}
+}
+
+// stubBulkSuccessTransport answers every _bulk request with a 201 success
+// for each action actually present in the request body, keyed by _id, so
+// tests can assert on which items a given enqueue/flush path actually sent.
+type stubBulkSuccessTransport struct{}
+
+func (stubBulkSuccessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []string
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var action map[string]struct {
+			ID string `json:"_id"`
+		}
+		if err := json.Unmarshal(line, &action); err != nil {
+			continue
+		}
+		for _, meta := range action {
+			items = append(items, fmt.Sprintf(`{"index":{"_id":%q,"status":201}}`, meta.ID))
+		}
+	}
+
+	resp := fmt.Sprintf(`{"items":[%s]}`, strings.Join(items, ","))
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(resp)),
+		Header:     make(http.Header),
+	}, nil
+}
+
+// TestBulkWriterFlushInterval verifies that an item queued below the
+// MaxActions and MaxBytes thresholds is still flushed once FlushInterval
+// elapses, exercising the ticker-driven path in flushLoop.
+func TestBulkWriterFlushInterval(t *testing.T) {
+	confirms := make(chan struct{}, 1)
+	opts := &clients.ClientOptions{
+		URLs:       []string{testURL},
+		HTTPClient: &http.Client{Transport: stubBulkSuccessTransport{}},
+		Index:      defaultIndex,
+		Bulk: &clients.BulkOptions{
+			MaxActions:    10,
+			FlushInterval: 20 * time.Millisecond,
+		},
+	}
+	vc := clients.Clients["v7"]
+	w, _ := vc.Creator(opts)
+	defer w.(client.Closer).Close()
+
+	w.Write(
+		message.WithConfirms(
+			confirms,
+			message.From(ops.Insert, testType, map[string]interface{}{"_id": "ticked"})),
+	)(nil)
+
+	select {
+	case <-confirms:
+	case <-time.After(1 * time.Second):
+		t.Fatal("expected the flush ticker to flush the queued item")
+	}
+}
+
+// TestBulkWriterMaxBytes verifies that a single item whose encoded size
+// alone crosses MaxBytes is flushed immediately, without waiting for
+// MaxActions or FlushInterval.
+func TestBulkWriterMaxBytes(t *testing.T) {
+	confirms := make(chan struct{}, 1)
+	opts := &clients.ClientOptions{
+		URLs:       []string{testURL},
+		HTTPClient: &http.Client{Transport: stubBulkSuccessTransport{}},
+		Index:      defaultIndex,
+		Bulk: &clients.BulkOptions{
+			MaxActions: 100,
+			MaxBytes:   10,
+		},
+	}
+	vc := clients.Clients["v7"]
+	w, _ := vc.Creator(opts)
+	defer w.(client.Closer).Close()
+
+	w.Write(
+		message.WithConfirms(
+			confirms,
+			message.From(ops.Insert, testType, map[string]interface{}{"_id": "big", "payload": strings.Repeat("x", 100)})),
+	)(nil)
+
+	select {
+	case <-confirms:
+	case <-time.After(1 * time.Second):
+		t.Fatal("expected the MaxBytes threshold to trigger an immediate flush")
+	}
+}
+
+// TestBulkCloseTimeout verifies that Close bounds its final flush by
+// ClientOptions.FlushTimeout, so a stalled cluster causes Close to return
+// rather than block indefinitely.
+func TestBulkCloseTimeout(t *testing.T) {
+	confirms := make(chan struct{}, 1)
+	opts := &clients.ClientOptions{
+		URLs:         []string{testURL},
+		HTTPClient:   &http.Client{Transport: blockingTransport{}},
+		Index:        defaultIndex,
+		FlushTimeout: 10 * time.Millisecond,
+		Bulk: &clients.BulkOptions{
+			MaxActions: 10,
+		},
+	}
+	vc := clients.Clients["v7"]
+	w, _ := vc.Creator(opts)
+
+	w.Write(
+		message.WithConfirms(
+			confirms,
+			message.From(ops.Insert, testType, map[string]interface{}{"_id": "stuck"})),
+	)(nil)
+
+	done := make(chan struct{})
+	go func() {
+		w.(client.Closer).Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(1 * time.Second):
+		t.Fatal("Close did not return within FlushTimeout's grace period")
+	}
+
+	select {
+	case <-confirms:
+		t.Error("expected no confirmation for a flush aborted by FlushTimeout")
+	default:
+	}
+}
+
+// TestBulkCloseIdempotent verifies that calling Close a second time returns
+// cleanly instead of panicking on a double close of the stop channel.
+func TestBulkCloseIdempotent(t *testing.T) {
+	opts := &clients.ClientOptions{
+		URLs:       []string{testURL},
+		HTTPClient: &http.Client{Transport: stubBulkSuccessTransport{}},
+		Index:      defaultIndex,
+		Bulk: &clients.BulkOptions{
+			MaxActions: 10,
+		},
+	}
+	vc := clients.Clients["v7"]
+	w, _ := vc.Creator(opts)
+
+	if err := w.(client.Closer).Close(); err != nil {
+		t.Fatalf("first Close returned an error: %s", err)
+	}
+	if err := w.(client.Closer).Close(); err != nil {
+		t.Fatalf("second Close returned an error: %s", err)
+	}
+}
@@ -0,0 +1,58 @@
+package v7
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"transporter/adaptor/elasticsearch/clients"
+	"transporter/message"
+	"transporter/message/ops"
+)
+
+// blockingTransport never returns on its own; it only resolves once the
+// request's context is done, at which point it reports that context's error.
+type blockingTransport struct{}
+
+func (blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+// TestWriteContextCancellation verifies that cancelling the context passed
+// to WriteContext aborts the in-flight request and surfaces an error
+// instead of a confirmation.
+func TestWriteContextCancellation(t *testing.T) {
+	confirms := make(chan struct{}, 1)
+	opts := &clients.ClientOptions{
+		URLs:       []string{testURL},
+		HTTPClient: &http.Client{Transport: blockingTransport{}},
+		Index:      defaultIndex,
+	}
+	vc := clients.Clients["v7"]
+	w, _ := vc.Creator(opts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	msg := message.WithConfirms(
+		confirms,
+		message.From(ops.Insert, testType, map[string]interface{}{"_id": "cancelme", "hello": "world"}),
+	)
+	_, err := w.(*Writer).WriteContext(ctx, msg)(nil)
+
+	if err == nil {
+		t.Fatal("expected an error from a cancelled write, got nil")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	select {
+	case <-confirms:
+		t.Error("expected no confirmation for a cancelled write")
+	default:
+	}
+}
@@ -0,0 +1,126 @@
+package v7
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"transporter/message"
+	"transporter/message/ops"
+)
+
+// opMeta carries the per-operation metadata pulled out of a message's
+// document before it's sent: the document id, and the optional routing,
+// ingest pipeline, and optimistic-concurrency fields Elasticsearch exposes
+// alongside it. version, ifSeqNo, and ifPrimaryTerm are kept as
+// json.Number, rather than string, so that bulkMeta can encode them as
+// JSON numbers while queryString can still render them as plain strings
+// for a URL query.
+type opMeta struct {
+	id            string
+	routing       string
+	pipeline      string
+	version       json.Number
+	versionType   string
+	opType        string
+	ifSeqNo       json.Number
+	ifPrimaryTerm json.Number
+}
+
+// prepare extracts the document id and any reserved metadata keys from
+// msg's data, rewrites the remaining document to carry the join field
+// required by the mapping, and returns the resulting opMeta alongside the
+// cleaned document.
+func (w *Writer) prepare(msg message.Msg) (opMeta, map[string]interface{}) {
+	d := map[string]interface{}(msg.Data())
+	table := msg.Namespace()
+
+	var meta opMeta
+	meta.id, _ = d["_id"].(string)
+	delete(d, "_id")
+
+	meta.routing = w.applyJoinField(table, d)
+	if v, ok := takeString(d, "routing"); ok {
+		meta.routing = v
+	}
+
+	meta.pipeline = w.opts.Pipeline
+	if v, ok := takeString(d, "pipeline"); ok {
+		meta.pipeline = v
+	}
+
+	meta.version, _ = takeNumber(d, "version")
+	meta.versionType, _ = takeString(d, "version_type")
+	meta.opType, _ = takeString(d, "op_type")
+	meta.ifSeqNo, _ = takeNumber(d, "if_seq_no")
+	meta.ifPrimaryTerm, _ = takeNumber(d, "if_primary_term")
+
+	return meta, d
+}
+
+// takeString returns the string form of d[key] and deletes it from d, if
+// present.
+func takeString(d map[string]interface{}, key string) (string, bool) {
+	v, ok := d[key]
+	if !ok {
+		return "", false
+	}
+	delete(d, key)
+	return fmt.Sprint(v), true
+}
+
+// takeNumber returns the json.Number form of d[key] and deletes it from d,
+// if present. It accepts a value already decoded as a number (int,
+// float64) or as a numeric string, since a document's "version",
+// "if_seq_no", and "if_primary_term" fields may arrive either way
+// depending on how the source data was encoded.
+func takeNumber(d map[string]interface{}, key string) (json.Number, bool) {
+	v, ok := d[key]
+	if !ok {
+		return "", false
+	}
+	delete(d, key)
+	return json.Number(fmt.Sprint(v)), true
+}
+
+// queryString renders the URL query parameters a single-document request
+// for op should carry. pipeline, version, version_type, and op_type only
+// apply to index/create/update requests, not deletes.
+func (m opMeta) queryString(op ops.Op) string {
+	v := url.Values{}
+	if m.routing != "" {
+		v.Set("routing", m.routing)
+	}
+	if m.ifSeqNo != "" {
+		v.Set("if_seq_no", m.ifSeqNo.String())
+	}
+	if m.ifPrimaryTerm != "" {
+		v.Set("if_primary_term", m.ifPrimaryTerm.String())
+	}
+	if op != ops.Delete {
+		if m.pipeline != "" {
+			v.Set("pipeline", m.pipeline)
+		}
+		if m.version != "" {
+			v.Set("version", m.version.String())
+		}
+		if m.versionType != "" {
+			v.Set("version_type", m.versionType)
+		}
+		if m.opType != "" {
+			v.Set("op_type", m.opType)
+		}
+	}
+	return v.Encode()
+}
+
+// VersionConflictError indicates Elasticsearch rejected a write because of
+// an optimistic-concurrency mismatch (a 409 response), as distinct from a
+// transport-level failure.
+type VersionConflictError struct {
+	ID string
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("version conflict writing document %q", e.ID)
+}
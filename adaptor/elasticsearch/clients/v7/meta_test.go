@@ -0,0 +1,149 @@
+package v7
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"transporter/adaptor"
+	"transporter/adaptor/elasticsearch/clients"
+	"transporter/client"
+	"transporter/message"
+	"transporter/message/ops"
+)
+
+const testPipeline = "test_v7_pipeline"
+
+func createPipeline() error {
+	body := []byte(`{"processors": [{"set": {"field": "pipeline_ran", "value": true}}]}`)
+	req, _ := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/_ingest/pipeline/%s", testURL, testPipeline), bytes.NewBuffer(body))
+	_, err := http.DefaultClient.Do(req)
+	return err
+}
+
+// TestWriterWithPipeline verifies that a document written through a
+// configured ingest pipeline is actually processed by it.
+func TestWriterWithPipeline(t *testing.T) {
+	confirms, cleanup := adaptor.MockConfirmWrites()
+	defer adaptor.VerifyWriteConfirmed(cleanup, t)
+	if err := createPipeline(); err != nil {
+		t.Fatalf("unable to create pipeline, %s", err)
+	}
+	opts := &clients.ClientOptions{
+		URLs:       []string{testURL},
+		HTTPClient: http.DefaultClient,
+		Index:      defaultIndex,
+		Pipeline:   testPipeline,
+	}
+	vc := clients.Clients["v7"]
+	w, _ := vc.Creator(opts)
+	w.Write(
+		message.WithConfirms(
+			confirms,
+			message.From(ops.Insert, testType, map[string]interface{}{"_id": "piped", "hello": "world"})),
+	)(nil)
+	w.(client.Closer).Close()
+
+	if _, err := http.Get(fullURL("/_refresh")); err != nil {
+		t.Fatalf("_refresh request failed, %s", err)
+	}
+	time.Sleep(1 * time.Second)
+
+	resp, err := http.Get(fullURL("/_doc/piped"))
+	if err != nil {
+		t.Fatalf("_doc request failed, %s", err)
+	}
+	defer resp.Body.Close()
+	var doc struct {
+		Source struct {
+			PipelineRan bool `json:"pipeline_ran"`
+		} `json:"_source"`
+	}
+	json.NewDecoder(resp.Body).Decode(&doc)
+	if !doc.Source.PipelineRan {
+		t.Errorf("expected the pipeline to have set pipeline_ran, got %+v", doc.Source)
+	}
+}
+
+// stubStatusTransport answers every request with a fixed status code,
+// regardless of method or body.
+type stubStatusTransport struct {
+	status int
+}
+
+func (s stubStatusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: s.status,
+		Body:       io.NopCloser(strings.NewReader(`{}`)),
+		Header:     make(http.Header),
+	}, nil
+}
+
+// TestWriteVersionConflict verifies a 409 response surfaces as a typed
+// *VersionConflictError rather than a generic transport error.
+func TestWriteVersionConflict(t *testing.T) {
+	opts := &clients.ClientOptions{
+		URLs:       []string{testURL},
+		HTTPClient: &http.Client{Transport: stubStatusTransport{status: http.StatusConflict}},
+		Index:      defaultIndex,
+	}
+	vc := clients.Clients["v7"]
+	w, _ := vc.Creator(opts)
+	_, err := w.Write(
+		message.From(ops.Insert, testType, map[string]interface{}{
+			"_id":          "conflict",
+			"hello":        "world",
+			"version":      1,
+			"version_type": "external",
+		}),
+	)(nil)
+
+	var vcErr *VersionConflictError
+	if !errors.As(err, &vcErr) {
+		t.Fatalf("expected *VersionConflictError, got %v (%T)", err, err)
+	}
+	if vcErr.ID != "conflict" {
+		t.Errorf("expected ID %q, got %q", "conflict", vcErr.ID)
+	}
+}
+
+// TestBulkMetaNumericFields verifies that version, if_seq_no, and
+// if_primary_term are encoded as JSON numbers in a bulk action's metadata
+// line, matching the type Elasticsearch's bulk API documents for them,
+// rather than as quoted strings.
+func TestBulkMetaNumericFields(t *testing.T) {
+	meta := opMeta{
+		id:            "1",
+		version:       "2",
+		ifSeqNo:       "10",
+		ifPrimaryTerm: "1",
+	}
+	action, _, err := buildBulkAction(ops.Insert, defaultIndex, meta, map[string]interface{}{"hello": "world"})
+	if err != nil {
+		t.Fatalf("buildBulkAction returned an error: %s", err)
+	}
+
+	var decoded struct {
+		Index struct {
+			Version       json.Number `json:"version"`
+			IfSeqNo       json.Number `json:"if_seq_no"`
+			IfPrimaryTerm json.Number `json:"if_primary_term"`
+		} `json:"index"`
+	}
+	if err := json.Unmarshal(action, &decoded); err != nil {
+		t.Fatalf("unable to decode bulk action, %s", err)
+	}
+	if decoded.Index.Version != "2" || decoded.Index.IfSeqNo != "10" || decoded.Index.IfPrimaryTerm != "1" {
+		t.Errorf("unexpected decoded metadata: %+v", decoded.Index)
+	}
+
+	if strings.Contains(string(action), `"version":"2"`) {
+		t.Errorf("expected version to be encoded as a JSON number, got quoted string in %s", action)
+	}
+}
@@ -0,0 +1,200 @@
+// Package v7 implements a client.Writer that indexes, updates, and deletes
+// documents against an Elasticsearch 7.x cluster over its REST API.
+//
+// Elasticsearch 7 removed mapping types, so parent/child relationships can
+// no longer be expressed with a `_parent` field. Instead this writer models
+// them with the join datatype: a JoinField name and a set of Relations are
+// declared on clients.ClientOptions, and child documents carry their
+// parent's id under the configured ParentID key.
+package v7
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"transporter/adaptor/elasticsearch/clients"
+	"transporter/client"
+	"transporter/log"
+	"transporter/message"
+	"transporter/message/ops"
+)
+
+func init() {
+	clients.Add("v7", func(opts *clients.ClientOptions) (client.Writer, error) {
+		return NewWriter(opts)
+	})
+}
+
+// Writer sends messages to an Elasticsearch 7.x cluster. When opts.Bulk is
+// set, writes are accumulated and flushed through the _bulk API instead of
+// being issued one request at a time.
+type Writer struct {
+	opts *clients.ClientOptions
+
+	bulk *bulkQueue
+}
+
+// NewWriter returns a Writer configured with opts. If opts.HTTPClient is
+// unset, one is built from opts.TLS.
+func NewWriter(opts *clients.ClientOptions) (*Writer, error) {
+	hc, err := buildHTTPClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.HTTPClient = hc
+
+	w := &Writer{opts: opts}
+	if opts.Bulk != nil {
+		w.bulk = newBulkQueue(w)
+	}
+	return w, nil
+}
+
+// parentOf returns the join relation name that table belongs to as a child,
+// and ok is true when table is in fact declared as a child relation.
+func (w *Writer) parentOf(table string) (relation string, ok bool) {
+	for parent, children := range w.opts.Relations {
+		for _, child := range children {
+			if child == table {
+				return parent, true
+			}
+		}
+	}
+	return "", false
+}
+
+// applyJoinField rewrites d in place to carry the join field required by
+// the mapping, returning the routing value to use for the request, if any.
+func (w *Writer) applyJoinField(table string, d map[string]interface{}) string {
+	if w.opts.JoinField == "" {
+		return ""
+	}
+	if _, isChild := w.parentOf(table); isChild {
+		parentID, _ := d[w.opts.ParentID].(string)
+		delete(d, w.opts.ParentID)
+		d[w.opts.JoinField] = map[string]interface{}{
+			"name":   table,
+			"parent": parentID,
+		}
+		return parentID
+	}
+	if _, isParent := w.opts.Relations[table]; isParent {
+		d[w.opts.JoinField] = table
+	}
+	return ""
+}
+
+// Write indexes, updates, or deletes the document represented by msg. It is
+// equivalent to WriteContext with a background context that never times out
+// on its own; callers that need a deadline or the ability to cancel a slow
+// write should call WriteContext directly.
+func (w *Writer) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	return w.WriteContext(context.Background(), msg)
+}
+
+// WriteContext indexes, updates, or deletes the document represented by msg,
+// honoring ctx's deadline and cancellation for the underlying HTTP request.
+// When bulk mode is enabled the operation is queued and flushed
+// asynchronously, governed instead by ClientOptions.FlushTimeout.
+func (w *Writer) WriteContext(ctx context.Context, msg message.Msg) func(client.Session) (message.Msg, error) {
+	return func(_ client.Session) (message.Msg, error) {
+		meta, d := w.prepare(msg)
+
+		if w.bulk != nil {
+			w.bulk.enqueue(msg, msg.OP(), meta, d)
+			return msg, nil
+		}
+
+		ctx, cancel := w.withTimeout(ctx, w.opts.RequestTimeout)
+		defer cancel()
+
+		req, err := w.request(ctx, msg.OP(), meta, d)
+		if err != nil {
+			return msg, err
+		}
+
+		resp, err := w.opts.HTTPClient.Do(req)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return msg, ctxErr
+			}
+			return msg, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusConflict {
+			return msg, &VersionConflictError{ID: meta.id}
+		}
+		if resp.StatusCode >= 300 {
+			return msg, fmt.Errorf("elasticsearch request failed with status %d", resp.StatusCode)
+		}
+
+		log.Debugf("wrote %s %s %s", msg.OP(), msg.Namespace(), meta.id)
+		message.Confirms(msg)
+		return msg, nil
+	}
+}
+
+// withTimeout derives a child of ctx bounded by timeout, unless timeout is
+// zero in which case ctx is returned unchanged.
+func (w *Writer) withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (w *Writer) request(ctx context.Context, op ops.Op, meta opMeta, d map[string]interface{}) (*http.Request, error) {
+	base := fmt.Sprintf("%s/%s", w.opts.URLs[0], w.opts.Index)
+
+	var (
+		method string
+		path   string
+		body   []byte
+		err    error
+	)
+	switch op {
+	case ops.Delete:
+		method, path = http.MethodDelete, fmt.Sprintf("%s/_doc/%s", base, meta.id)
+	case ops.Update:
+		method, path = http.MethodPost, fmt.Sprintf("%s/_update/%s", base, meta.id)
+		body, err = json.Marshal(map[string]interface{}{"doc": d})
+	default:
+		if meta.id == "" {
+			method, path = http.MethodPost, fmt.Sprintf("%s/_doc", base)
+		} else {
+			method, path = http.MethodPut, fmt.Sprintf("%s/_doc/%s", base, meta.id)
+		}
+		body, err = json.Marshal(d)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if q := meta.queryString(op); q != "" {
+		path = path + "?" + q
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	setAuth(w.opts, req)
+	return req, nil
+}
+
+// Close satisfies client.Closer. In bulk mode it stops the background flush
+// loop and synchronously drains any pending operations; otherwise there is
+// nothing to flush since every write is already synchronous. It is safe to
+// call Close more than once.
+func (w *Writer) Close() error {
+	if w.bulk != nil {
+		return w.bulk.close()
+	}
+	return nil
+}
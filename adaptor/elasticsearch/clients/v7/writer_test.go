@@ -51,8 +51,9 @@ func clearTestData() error {
 	return err
 }
 func createMapping() error {
-	// create a simple mapping one company has many employees
-	var mapping = []byte(`{"mappings": {"company": {}, "employee": {"_parent": {"type": "company"} } } }`)
+	// create a single-type mapping where a join field relates companies
+	// (the parent relation) to employees (the child relation)
+	var mapping = []byte(`{"mappings": {"properties": {"my_join": {"type": "join", "relations": {"company": "employee"}}}}}`)
 	req, _ := http.NewRequest("PUT", parentFullURL(""), bytes.NewBuffer(mapping))
 	_, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -86,10 +87,14 @@ type elasticResponse struct {
 	Hits  struct {
 		Hits []struct {
 			ID      string `json:"_id"`
-			Parent  string `json:"_parent"`
 			Routing string `json:"_routing"`
-			Name    string `json:"name"`
-			Type    string `json:"_type"`
+			Source  struct {
+				Name   string `json:"name"`
+				MyJoin struct {
+					Name   string `json:"name"`
+					Parent string `json:"parent"`
+				} `json:"my_join"`
+			} `json:"_source"`
 		} `json:"hits"`
 	} `json:"hits"`
 }
@@ -157,6 +162,8 @@ func TestWithParentWriter(t *testing.T) {
 		HTTPClient: http.DefaultClient,
 		Index:      parentDefaultIndex,
 		ParentID:   "parent_id",
+		JoinField:  "my_join",
+		Relations:  map[string][]string{"company": {"employee"}},
 	}
 	// create mapping
 	createMapping()
@@ -168,17 +175,17 @@ func TestWithParentWriter(t *testing.T) {
 			confirms,
 			message.From(ops.Insert, "company", map[string]interface{}{"_id": "9g2g", "name": "gingerbreadhouse"})),
 	)(nil)
-	// insert child
+	// insert child, routed to its parent via the join field
 	w.Write(
 		message.WithConfirms(
 			confirms,
-			message.From(ops.Insert, "employee", map[string]interface{}{"_id": "9g6g", "name": "witch", "parent_id": "gingerbreadhouse"})),
+			message.From(ops.Insert, "employee", map[string]interface{}{"_id": "9g6g", "name": "witch", "parent_id": "9g2g"})),
 	)(nil)
 	// update child
 	w.Write(
 		message.WithConfirms(
 			confirms,
-			message.From(ops.Update, "employee", map[string]interface{}{"_id": "9g6g", "name": "wickedwitch", "parent_id": "gingerbreadhouse"})),
+			message.From(ops.Update, "employee", map[string]interface{}{"_id": "9g6g", "name": "wickedwitch", "parent_id": "9g2g"})),
 	)(nil)
 	w.(client.Closer).Close()
 	if _, err := http.Get(parentFullURL("/_refresh")); err != nil {
@@ -197,25 +204,25 @@ func TestWithParentWriter(t *testing.T) {
 	if r.Count != 2 {
 		t.Errorf("mismatched doc count, expected 2, got %d", r.Count)
 	}
-	employeeResp, err := http.Get(parentFullURL("/employee/_search"))
+	employeeResp, err := http.Get(parentFullURL("/_search?q=my_join:employee"))
 	if err != nil {
 		t.Fatalf("_count request failed, %s", err)
 	}
 	defer employeeResp.Body.Close()
 
 	var par elasticResponse
-	// decode and make sure that _parent is in the json response
+	// decode and make sure that the join field's parent is in the json response
 	json.NewDecoder(employeeResp.Body).Decode(&par)
-	if par.Hits.Hits[0].Parent != "gingerbreadhouse" {
-		t.Errorf("mismatched _parent, got %d", par.Hits.Hits[0].Parent)
+	if par.Hits.Hits[0].Source.MyJoin.Parent != "9g2g" {
+		t.Errorf("mismatched my_join.parent, got %s", par.Hits.Hits[0].Source.MyJoin.Parent)
 	}
-	// decode and make sure that _parent and _routing is in the json response
-	if par.Hits.Hits[0].Routing != par.Hits.Hits[0].Parent {
-		t.Errorf("mismatched _routing does not equal _parent, got %d", par.Hits.Hits[0].Parent)
+	// decode and make sure that _routing equals the join parent
+	if par.Hits.Hits[0].Routing != par.Hits.Hits[0].Source.MyJoin.Parent {
+		t.Errorf("mismatched _routing does not equal my_join.parent, got %s", par.Hits.Hits[0].Routing)
 	}
-	// decode and make sure that _parent and _routing is in the json response
-	if par.Hits.Hits[0].Name == "wickedwitch" {
-		t.Errorf("mismatched _routing does not equal _parent, got %d", par.Hits.Hits[0].Parent)
+	// decode and make sure the update was applied
+	if par.Hits.Hits[0].Source.Name != "wickedwitch" {
+		t.Errorf("mismatched name, expected wickedwitch, got %s", par.Hits.Hits[0].Source.Name)
 	}
 
 	w2, _ := vc.Creator(opts)
@@ -223,11 +230,11 @@ func TestWithParentWriter(t *testing.T) {
 	w2.Write(
 		message.WithConfirms(
 			confirms,
-			message.From(ops.Delete, "employee", map[string]interface{}{"_id": "9g6g", "name": "wickedwitch", "parent_id": "gingerbreadhouse"})),
+			message.From(ops.Delete, "employee", map[string]interface{}{"_id": "9g6g", "name": "wickedwitch", "parent_id": "9g2g"})),
 	)(nil)
 	w2.(client.Closer).Close()
 	time.Sleep(1 * time.Second)
-	deletedCountResp, err := http.Get(parentFullURL("/employee/_count"))
+	deletedCountResp, err := http.Get(parentFullURL("/_count?q=my_join:employee"))
 	if err != nil {
 		t.Fatalf("_count request failed, %s", err)
 	}